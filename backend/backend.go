@@ -0,0 +1,42 @@
+// Package backend abstracts the storage a sync side reads from or writes
+// to, so the same scan/compare/pull pipeline can run between any two kinds
+// of storage (local disk, WebDAV, S3, ...) instead of only local paths.
+package backend
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Entry describes one item (file or directory) addressed by a Backend, in
+// terms generic enough to be filled in by any implementation.
+type Entry struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Backend is a storage side of a sync: everything scanFolder, doPull and
+// doDelete need, expressed without assuming a local filesystem. Paths are
+// always relative to whatever root the Backend was constructed with.
+type Backend interface {
+	ReadDir(path string) ([]Entry, error)
+	Open(path string) (io.ReadCloser, error)
+	Create(path string, mode os.FileMode) (io.WriteCloser, error)
+	Mkdir(path string, mode os.FileMode) error
+	Remove(path string) error
+	Stat(path string) (Entry, error)
+}
+
+func entryFromFileInfo(fi os.FileInfo) Entry {
+	return Entry{
+		Name:    fi.Name(),
+		Size:    fi.Size(),
+		Mode:    fi.Mode(),
+		ModTime: fi.ModTime(),
+		IsDir:   fi.IsDir(),
+	}
+}
@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// LocalBackend implements Backend over the local filesystem, rooted at Root.
+type LocalBackend struct {
+	Root string
+}
+
+// NewLocalBackend returns a Backend rooted at the given local directory.
+func NewLocalBackend(root string) *LocalBackend {
+	return &LocalBackend{Root: root}
+}
+
+// Join returns the absolute local path for a path relative to Root. Callers
+// that need to bypass the Backend interface for local-to-local fast paths
+// (e.g. the block puller) can use this to get back a real filesystem path.
+func (b *LocalBackend) Join(p string) string {
+	return path.Join(b.Root, p)
+}
+
+func (b *LocalBackend) ReadDir(p string) ([]Entry, error) {
+	fs, e := ioutil.ReadDir(b.Join(p))
+	if e != nil {
+		return nil, e
+	}
+	entries := make([]Entry, 0, len(fs))
+	for _, f := range fs {
+		entries = append(entries, entryFromFileInfo(f))
+	}
+	return entries, nil
+}
+
+func (b *LocalBackend) Open(p string) (io.ReadCloser, error) {
+	return os.Open(b.Join(p))
+}
+
+func (b *LocalBackend) Create(p string, mode os.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(b.Join(p), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+}
+
+func (b *LocalBackend) Mkdir(p string, mode os.FileMode) error {
+	e := os.Mkdir(b.Join(p), mode)
+	if os.IsExist(e) {
+		return nil
+	}
+	return e
+}
+
+func (b *LocalBackend) Remove(p string) error {
+	return os.Remove(b.Join(p))
+}
+
+func (b *LocalBackend) Stat(p string) (Entry, error) {
+	fi, e := os.Stat(b.Join(p))
+	if e != nil {
+		return Entry{}, e
+	}
+	return entryFromFileInfo(fi), nil
+}
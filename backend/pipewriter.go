@@ -0,0 +1,36 @@
+package backend
+
+import "io"
+
+// pipeWriter adapts a whole-body-at-once (or path-at-a-time) upload
+// function to the io.WriteCloser the Backend interface expects, by
+// piping written bytes through to it as they arrive. S3Backend and
+// WebDAVBackend's writers are both built on this; the only difference
+// between them is what upload does with the piped reader.
+type pipeWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func newPipeWriter(upload func(io.Reader) error) *pipeWriter {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		e := upload(pr)
+		pr.CloseWithError(e)
+		done <- e
+	}()
+	return &pipeWriter{pw: pw, done: done}
+}
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close blocks until upload has finished and returns whatever error it
+// produced, so a caller treating a nil Close error as "write succeeded"
+// is actually right.
+func (w *pipeWriter) Close() error {
+	w.pw.Close()
+	return <-w.done
+}
@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func Test_PipeWriter_ClosePropagatesWrittenBytes(t *testing.T) {
+	var got []byte
+	w := newPipeWriter(func(r io.Reader) error {
+		b, e := ioutil.ReadAll(r)
+		got = b
+		return e
+	})
+
+	if _, e := w.Write([]byte("hello")); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := w.Close(); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if string(got) != "hello" {
+		t.Fatalf("uploaded bytes = %q, want %q", got, "hello")
+	}
+}
+
+func Test_PipeWriter_ClosePropagatesUploadError(t *testing.T) {
+	uploadErr := errors.New("upload failed")
+	w := newPipeWriter(func(r io.Reader) error {
+		io.Copy(ioutil.Discard, r)
+		return uploadErr
+	})
+
+	if _, e := w.Write([]byte("hello")); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := w.Close(); e != uploadErr {
+		t.Fatalf("Close() error = %v, want %v", e, uploadErr)
+	}
+}
+
+func Test_PipeWriter_CloseBlocksUntilUploadFinishes(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	w := newPipeWriter(func(r io.Reader) error {
+		close(started)
+		<-release
+		_, e := io.Copy(ioutil.Discard, r)
+		return e
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- w.Close() }()
+
+	<-started
+	select {
+	case <-done:
+		t.Fatalf("Close returned before the upload finished")
+	default:
+	}
+	close(release)
+
+	if e := <-done; e != nil {
+		t.Fatalf(e.Error())
+	}
+}
@@ -0,0 +1,149 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Backend implements Backend over an S3 bucket. S3 has no real
+// directories, so a "/"-delimited common-prefix query is used to make key
+// prefixes look like directories the way most S3 browsing tools do.
+type S3Backend struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Backend returns a Backend over bucket, rooted at prefix.
+func NewS3Backend(client *s3.Client, bucket, prefix string) *S3Backend {
+	return &S3Backend{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+func (b *S3Backend) key(p string) string {
+	return path.Join(b.prefix, p)
+}
+
+func (b *S3Backend) ReadDir(p string) ([]Entry, error) {
+	prefix := b.key(p)
+	if prefix != "" {
+		prefix += "/"
+	}
+	out, e := b.client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
+		Bucket:    aws.String(b.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	if e != nil {
+		return nil, e
+	}
+
+	entries := []Entry{}
+	for _, cp := range out.CommonPrefixes {
+		name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(cp.Prefix), prefix), "/")
+		entries = append(entries, Entry{Name: name, IsDir: true})
+	}
+	for _, obj := range out.Contents {
+		name := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+		if name == "" {
+			continue
+		}
+		entries = append(entries, Entry{
+			Name:    name,
+			Size:    aws.ToInt64(obj.Size),
+			ModTime: aws.ToTime(obj.LastModified),
+		})
+	}
+	return entries, nil
+}
+
+func (b *S3Backend) Open(p string) (io.ReadCloser, error) {
+	out, e := b.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(p)),
+	})
+	if e != nil {
+		return nil, e
+	}
+	return out.Body, nil
+}
+
+func (b *S3Backend) Create(p string, mode os.FileMode) (io.WriteCloser, error) {
+	bucket, key := b.bucket, b.key(p)
+	return newPipeWriter(func(r io.Reader) error {
+		_, e := manager.NewUploader(b.client).Upload(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   r,
+		})
+		return e
+	}), nil
+}
+
+func (b *S3Backend) Mkdir(p string, mode os.FileMode) error {
+	_, e := b.client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(p) + "/"),
+		Body:   bytes.NewReader(nil),
+	})
+	return e
+}
+
+func (b *S3Backend) Remove(p string) error {
+	_, e := b.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(p)),
+	})
+	return e
+}
+
+// Stat treats the backend's own root, and any key with objects beneath
+// it, as a directory - S3 has no object for a prefix itself, the way
+// ReadDir already infers directories from CommonPrefixes rather than a
+// real entry.
+func (b *S3Backend) Stat(p string) (Entry, error) {
+	key := b.key(p)
+	if key == "" {
+		return Entry{Name: path.Base(p), IsDir: true}, nil
+	}
+
+	out, e := b.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if e == nil {
+		return Entry{
+			Name:    path.Base(p),
+			Size:    aws.ToInt64(out.ContentLength),
+			ModTime: aws.ToTime(out.LastModified),
+		}, nil
+	}
+
+	hasPrefix, le := b.hasObjectsUnder(key + "/")
+	if le == nil && hasPrefix {
+		return Entry{Name: path.Base(p), IsDir: true}, nil
+	}
+	return Entry{}, e
+}
+
+// hasObjectsUnder reports whether any object key starts with prefix,
+// which is how S3Backend tells a "directory" apart from a path that
+// simply doesn't exist.
+func (b *S3Backend) hasObjectsUnder(prefix string) (bool, error) {
+	out, e := b.client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
+		Bucket:  aws.String(b.bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(1),
+	})
+	if e != nil {
+		return false, e
+	}
+	return len(out.Contents) > 0 || len(out.CommonPrefixes) > 0, nil
+}
@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// WebDAVBackend implements Backend over a WebDAV share.
+type WebDAVBackend struct {
+	client *gowebdav.Client
+	root   string
+}
+
+// NewWebDAVBackend returns a Backend talking WebDAV to endpoint, rooted at
+// root within that share.
+func NewWebDAVBackend(endpoint, user, pass, root string) *WebDAVBackend {
+	return &WebDAVBackend{client: gowebdav.NewClient(endpoint, user, pass), root: root}
+}
+
+func (b *WebDAVBackend) full(p string) string {
+	return path.Join(b.root, p)
+}
+
+func (b *WebDAVBackend) ReadDir(p string) ([]Entry, error) {
+	fs, e := b.client.ReadDir(b.full(p))
+	if e != nil {
+		return nil, e
+	}
+	entries := make([]Entry, 0, len(fs))
+	for _, f := range fs {
+		entries = append(entries, entryFromFileInfo(f))
+	}
+	return entries, nil
+}
+
+func (b *WebDAVBackend) Open(p string) (io.ReadCloser, error) {
+	return b.client.ReadStream(b.full(p))
+}
+
+func (b *WebDAVBackend) Create(p string, mode os.FileMode) (io.WriteCloser, error) {
+	client, full := b.client, b.full(p)
+	return newPipeWriter(func(r io.Reader) error {
+		return client.WriteStream(full, r, 0644)
+	}), nil
+}
+
+func (b *WebDAVBackend) Mkdir(p string, mode os.FileMode) error {
+	return b.client.Mkdir(b.full(p), mode)
+}
+
+func (b *WebDAVBackend) Remove(p string) error {
+	return b.client.Remove(b.full(p))
+}
+
+func (b *WebDAVBackend) Stat(p string) (Entry, error) {
+	fi, e := b.client.Stat(b.full(p))
+	if e != nil {
+		return Entry{}, e
+	}
+	return entryFromFileInfo(fi), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"yqsync/backend"
+)
+
+// BlockSize is the fixed block size used to split files for delta sync.
+const BlockSize = 128 * 1024
+
+// BlockInfo describes one fixed-size block of a file: its position and
+// length, a strong hash used to verify identity, and a weak rolling hash
+// used to locate matching regions that are not block-aligned in the
+// destination.
+type BlockInfo struct {
+	Offset   int64
+	Size     int
+	Hash     [sha256.Size]byte
+	WeakHash uint32
+}
+
+// blocksForEntry splits the file at path, read through b, into BlockSize
+// blocks and computes a strong and weak hash for each one.
+func blocksForEntry(b backend.Backend, path string) ([]BlockInfo, error) {
+	f, e := b.Open(path)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+
+	buf := make([]byte, BlockSize)
+	blocks := []BlockInfo{}
+	var offset int64
+	for {
+		n, e := io.ReadFull(f, buf)
+		if n > 0 {
+			blocks = append(blocks, BlockInfo{
+				Offset:   offset,
+				Size:     n,
+				Hash:     sha256.Sum256(buf[:n]),
+				WeakHash: weakHashSum(buf[:n]),
+			})
+			offset += int64(n)
+		}
+		if e == io.EOF || e == io.ErrUnexpectedEOF {
+			break
+		}
+		if e != nil {
+			return nil, e
+		}
+	}
+	return blocks, nil
+}
+
+// sameBlocks reports whether two block lists describe identical file content.
+func sameBlocks(a, b []BlockInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Size != b[i].Size || a[i].Hash != b[i].Hash {
+			return false
+		}
+	}
+	return true
+}
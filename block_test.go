@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"testing"
+
+	"yqsync/backend"
+)
+
+// blockOfSeed returns a BlockSize buffer of pseudo-random bytes so that
+// distinct blocks don't collide under the weak hash, the way real file
+// content wouldn't.
+func blockOfSeed(seed int64) []byte {
+	buf := make([]byte, BlockSize)
+	rand.New(rand.NewSource(seed)).Read(buf)
+	return buf
+}
+
+func writeTempFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	p := path.Join(dir, name)
+	if e := ioutil.WriteFile(p, content, 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+	return p
+}
+
+func mustPullFile(t *testing.T, dir string, srcContent []byte) []byte {
+	t.Helper()
+	writeTempFile(t, dir, "src.bin", srcContent)
+
+	b := backend.NewLocalBackend(dir)
+	blocks, e := blocksForEntry(b, "src.bin")
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	entry, e := b.Stat("src.bin")
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	f := fileInfo{Name: "dst.bin", Entry: entry, Blocks: blocks}
+
+	if e := pullRegularFileLocal(b.Join("src.bin"), b.Join("dst.bin"), b.Join(".yqsync/tmp"), f); e != nil {
+		t.Fatalf(e.Error())
+	}
+	got, e := ioutil.ReadFile(path.Join(dir, "dst.bin"))
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	return got
+}
+
+func Test_PullFile_ShiftedBlocks(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := blockOfSeed(1), blockOfSeed(2), blockOfSeed(3)
+
+	// dst already holds the blocks in a different order than src wants.
+	writeTempFile(t, dir, "dst.bin", append(append(append([]byte{}, b...), c...), a...))
+
+	src := append(append(append([]byte{}, a...), b...), c...)
+	got := mustPullFile(t, dir, src)
+	if !bytes.Equal(got, src) {
+		t.Fatalf("pulled content does not match source after block shift")
+	}
+}
+
+func Test_PullFile_DuplicatedBlocks(t *testing.T) {
+	dir := t.TempDir()
+	a, b := blockOfSeed(1), blockOfSeed(2)
+
+	writeTempFile(t, dir, "dst.bin", append(append([]byte{}, a...), a...))
+
+	src := append(append(append([]byte{}, a...), b...), a...)
+	got := mustPullFile(t, dir, src)
+	if !bytes.Equal(got, src) {
+		t.Fatalf("pulled content does not match source when a block is duplicated")
+	}
+}
+
+func Test_PullFile_DeletedBlock(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := blockOfSeed(1), blockOfSeed(2), blockOfSeed(3)
+
+	writeTempFile(t, dir, "dst.bin", append(append([]byte{}, a...), c...))
+
+	src := append(append(append([]byte{}, a...), b...), c...)
+	got := mustPullFile(t, dir, src)
+	if !bytes.Equal(got, src) {
+		t.Fatalf("pulled content does not match source when a middle block was removed")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import "sync"
+
+// hardlinkKey identifies an inode uniquely enough for one sync run:
+// (dev, inode) together, since inode numbers repeat across filesystems.
+type hardlinkKey struct {
+	dev, inode uint64
+}
+
+// hardlinkTracker lets every goroutine pulling a file that turns out to
+// share an inode with another one agree on which dst path gets the real
+// copy and which ones just os.Link to it, even though they all run
+// concurrently under doPull's worker pool.
+type hardlinkTracker struct {
+	mu    sync.Mutex
+	first map[hardlinkKey]string
+	ready map[hardlinkKey]chan struct{}
+}
+
+func newHardlinkTracker() *hardlinkTracker {
+	return &hardlinkTracker{
+		first: map[hardlinkKey]string{},
+		ready: map[hardlinkKey]chan struct{}{},
+	}
+}
+
+// claim reports whether the caller is the first to see key. The first
+// caller must pull path itself and then call done (or abandon, on
+// failure); every later caller gets back the first path and must call
+// wait before linking to it, since that copy may not have finished yet.
+func (t *hardlinkTracker) claim(key hardlinkKey, path string) (first string, isPrimary bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if first, ok := t.first[key]; ok {
+		return first, false
+	}
+	t.first[key] = path
+	t.ready[key] = make(chan struct{})
+	return path, true
+}
+
+func (t *hardlinkTracker) wait(key hardlinkKey) {
+	t.mu.Lock()
+	ch := t.ready[key]
+	t.mu.Unlock()
+	<-ch
+}
+
+func (t *hardlinkTracker) done(key hardlinkKey) {
+	t.mu.Lock()
+	ch := t.ready[key]
+	t.mu.Unlock()
+	close(ch)
+}
+
+// abandon releases key after the first copy failed, so nothing waiting
+// on it blocks forever. Those waiters will go on to fail their own link
+// against the never-written firstPath, which is fine: doPull already
+// fails the whole sync on any single file's error.
+func (t *hardlinkTracker) abandon(key hardlinkKey) {
+	t.done(key)
+}
@@ -0,0 +1,9 @@
+package ignore
+
+import "path"
+
+// globMatch matches a single path segment against a pattern segment using
+// shell-style wildcards (*, ?, [...]), same as path.Match.
+func globMatch(pattern, name string) (bool, error) {
+	return path.Match(pattern, name)
+}
@@ -0,0 +1,114 @@
+// Package ignore parses a gitignore-style pattern file and matches scanned
+// paths against it, so scanFolder can skip the entries it describes.
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Matcher holds an ordered list of ignore rules. Rules are evaluated in
+// file order and the last one that matches wins, which is what lets a
+// later "!pattern" line negate an earlier one.
+type Matcher struct {
+	rules []rule
+}
+
+type rule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// Parse reads gitignore-like lines from r: blank lines and lines starting
+// with "#" are skipped, a leading "!" negates the pattern, a leading "/"
+// anchors it to the root instead of letting it match at any depth, a
+// trailing "/" restricts it to directories, and "**" matches zero or more
+// path segments.
+func Parse(r io.Reader) (*Matcher, error) {
+	m := &Matcher{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rl := rule{}
+		if strings.HasPrefix(line, "!") {
+			rl.negate = true
+			line = line[1:]
+		}
+		if strings.HasPrefix(line, "/") {
+			rl.anchored = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rl.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rl.segments = strings.Split(line, "/")
+		m.rules = append(m.rules, rl)
+	}
+	return m, scanner.Err()
+}
+
+// Match reports whether relPath (slash-separated, relative to the scan
+// root) is ignored. isDir lets dir-only patterns apply only to directories.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	if m == nil || relPath == "" {
+		return false
+	}
+	segs := strings.Split(relPath, "/")
+
+	ignored := false
+	for _, rl := range m.rules {
+		if rl.dirOnly && !isDir {
+			continue
+		}
+		if matchRule(rl, segs) {
+			ignored = !rl.negate
+		}
+	}
+	return ignored
+}
+
+// matchRule reports whether rl matches somewhere in segs: at the root only
+// if anchored, or starting at any segment boundary otherwise.
+func matchRule(rl rule, segs []string) bool {
+	if rl.anchored {
+		return matchSegments(rl.segments, segs)
+	}
+	for i := range segs {
+		if matchSegments(rl.segments, segs[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches a pattern split on "/" against a path split on "/",
+// consuming the whole path; "**" consumes zero or more segments.
+func matchSegments(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return len(segs) == 0
+	}
+	if pat[0] == "**" {
+		if matchSegments(pat[1:], segs) {
+			return true
+		}
+		return len(segs) > 0 && matchSegments(pat, segs[1:])
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, e := globMatch(pat[0], segs[0]); e != nil || !ok {
+		return false
+	}
+	return matchSegments(pat[1:], segs[1:])
+}
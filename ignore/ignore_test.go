@@ -0,0 +1,61 @@
+package ignore
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, text string) *Matcher {
+	t.Helper()
+	m, e := Parse(strings.NewReader(text))
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	return m
+}
+
+func Test_Match_NegationOrdering(t *testing.T) {
+	m := mustParse(t, "*.log\n!important.log\n")
+	if !m.Match("debug.log", false) {
+		t.Fatalf("expected debug.log to be ignored")
+	}
+	if m.Match("important.log", false) {
+		t.Fatalf("expected important.log to be un-ignored by the later negation")
+	}
+
+	// A later re-ignore after a negation should win back over it.
+	m = mustParse(t, "*.log\n!important.log\nimportant.log\n")
+	if !m.Match("important.log", false) {
+		t.Fatalf("expected the last matching rule to win and re-ignore important.log")
+	}
+}
+
+func Test_Match_DirOnlyDoesNotMatchFile(t *testing.T) {
+	m := mustParse(t, "build/\n")
+	if m.Match("build", false) {
+		t.Fatalf("dir-only pattern must not match a file of the same name")
+	}
+	if !m.Match("build", true) {
+		t.Fatalf("dir-only pattern should match a directory of that name")
+	}
+}
+
+func Test_Match_RootAnchored(t *testing.T) {
+	m := mustParse(t, "/only-at-root.txt\n")
+	if !m.Match("only-at-root.txt", false) {
+		t.Fatalf("expected root-anchored pattern to match at the root")
+	}
+	if m.Match("nested/only-at-root.txt", false) {
+		t.Fatalf("root-anchored pattern must not match a nested path")
+	}
+}
+
+func Test_Match_RecursiveWildcard(t *testing.T) {
+	m := mustParse(t, "**/cache\n")
+	if !m.Match("cache", true) {
+		t.Fatalf("expected ** to also match zero leading segments")
+	}
+	if !m.Match("a/b/cache", true) {
+		t.Fatalf("expected ** to match any depth")
+	}
+}
@@ -0,0 +1,23 @@
+package ignore
+
+import (
+	"os"
+
+	"yqsync/backend"
+)
+
+// Load reads the ignore file at name (relative to src's root) through the
+// Backend and parses it. A missing file is not an error: it just means
+// nothing is ignored. Only the root ignore file is loaded; per-directory
+// ignore files are not yet supported.
+func Load(src backend.Backend, name string) (*Matcher, error) {
+	r, e := src.Open(name)
+	if e != nil {
+		if os.IsNotExist(e) {
+			return &Matcher{}, nil
+		}
+		return nil, e
+	}
+	defer r.Close()
+	return Parse(r)
+}
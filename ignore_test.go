@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"yqsync/backend"
+	"yqsync/versioner"
+)
+
+// Test_SyncFolder_IgnoredDstOnlyFileIsNotDeleted checks that a file which
+// only exists in dst, but matches a .yqignore pattern, is left alone rather
+// than scheduled for deletion.
+func Test_SyncFolder_IgnoredDstOnlyFileIsNotDeleted(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+
+	if e := ioutil.WriteFile(path.Join(srcDir, ".yqignore"), []byte("*.cache\n"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := ioutil.WriteFile(path.Join(srcDir, "a.txt"), []byte("hi"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := ioutil.WriteFile(path.Join(dstDir, "a.txt"), []byte("hi"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := ioutil.WriteFile(path.Join(dstDir, "build.cache"), []byte("leftover"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+
+	_, del, e := syncFolder(src, dst, true, versioner.None{}, ".yqignore")
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	for _, f := range del {
+		if f.Name == "build.cache" {
+			t.Fatalf("build.cache matches a .yqignore pattern and must not be scheduled for deletion")
+		}
+	}
+}
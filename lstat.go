@@ -0,0 +1,39 @@
+package main
+
+import "yqsync/backend"
+
+// lstatExtra holds the local-filesystem-only metadata lstatPlus reads:
+// symlink target, hardlink identity, and POSIX ownership/xattrs. None of
+// it is expressible through the generic Backend interface.
+type lstatExtra struct {
+	LinkTarget string
+	Dev        uint64
+	Inode      uint64
+	Nlink      uint32
+	UID        uint32
+	GID        uint32
+	Xattrs     map[string][]byte
+}
+
+// localMeta augments info with lstatExtra when b is a LocalBackend. Every
+// other Backend kind (WebDAV, S3, ...) doesn't expose any of this, so
+// info is returned unchanged - the same local-fast-path/generic-fallback
+// split doPull already makes for block-resuming.
+func localMeta(b backend.Backend, info fileInfo) (fileInfo, error) {
+	lb, ok := b.(*backend.LocalBackend)
+	if !ok {
+		return info, nil
+	}
+	extra, e := lstatPlus(lb.Join(info.Name))
+	if e != nil {
+		return fileInfo{}, e
+	}
+	info.LinkTarget = extra.LinkTarget
+	info.Dev = extra.Dev
+	info.Inode = extra.Inode
+	info.Nlink = extra.Nlink
+	info.UID = extra.UID
+	info.GID = extra.GID
+	info.Xattrs = extra.Xattrs
+	return info, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+// lstatPlus is a no-op outside Linux/macOS: yqsync still syncs by content
+// there, it just can't preserve symlinks, hardlinks, xattrs or ownership.
+func lstatPlus(path string) (lstatExtra, error) {
+	return lstatExtra{}, nil
+}
+
+// xattrLSet is a no-op outside Linux/macOS; lstatPlus never returns any
+// Xattrs there either, so applyOwnerAndXattrs has nothing to set.
+func xattrLSet(path, name string, value []byte) error {
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/xattr"
+)
+
+// lstatPlus reads the symlink target, hardlink identity and POSIX
+// ownership/xattrs of path directly off the local filesystem, without
+// following a symlink.
+func lstatPlus(path string) (lstatExtra, error) {
+	fi, e := os.Lstat(path)
+	if e != nil {
+		return lstatExtra{}, e
+	}
+
+	var extra lstatExtra
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		extra.Dev = uint64(st.Dev)
+		extra.Inode = uint64(st.Ino)
+		extra.Nlink = uint32(st.Nlink)
+		extra.UID = st.Uid
+		extra.GID = st.Gid
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, e := os.Readlink(path)
+		if e != nil {
+			return lstatExtra{}, e
+		}
+		extra.LinkTarget = target
+		return extra, nil
+	}
+
+	names, e := xattr.LList(path)
+	if e != nil {
+		return lstatExtra{}, e
+	}
+	for _, name := range names {
+		v, e := xattr.LGet(path, name)
+		if e != nil {
+			return lstatExtra{}, e
+		}
+		if extra.Xattrs == nil {
+			extra.Xattrs = map[string][]byte{}
+		}
+		extra.Xattrs[name] = v
+	}
+	return extra, nil
+}
+
+// xattrLSet sets a single extended attribute on path without following a
+// symlink.
+func xattrLSet(path, name string, value []byte) error {
+	return xattr.LSet(path, name, value)
+}
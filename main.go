@@ -2,26 +2,47 @@ package main
 
 import (
 	"bytes"
-	"crypto/md5"
+	"context"
 	"flag"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"path"
 	"runtime"
 	"runtime/pprof"
 	"sort"
 	"strings"
-	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"yqsync/backend"
+	"yqsync/ignore"
+	"yqsync/versioner"
 )
 
+// stagingRoot is the directory, relative to the destination root, that
+// yqsync's own bookkeeping (in-progress writes, trashed/staggered versions)
+// lives under. It is never synced itself.
+const stagingRoot = ".yqsync"
+
 type fileInfo struct {
-	Name string
-	Path string
-	Info os.FileInfo
+	Name   string
+	Entry  backend.Entry
+	Blocks []BlockInfo
+
+	// The following are only ever populated for a LocalBackend side (see
+	// localMeta in lstat.go); every other Backend kind leaves them zero.
+	LinkTarget string
+	Dev        uint64
+	Inode      uint64
+	Nlink      uint32
+	UID        uint32
+	GID        uint32
+	Xattrs     map[string][]byte
 }
 type fileInfos []fileInfo
 
@@ -30,205 +51,82 @@ func (f fileInfos) Len() int {
 }
 
 func (f fileInfos) Less(i, j int) bool {
-	if f[i].Info.IsDir() {
-		if !f[j].Info.IsDir() {
+	if f[i].Entry.IsDir {
+		if !f[j].Entry.IsDir {
 			return true
 		}
-	} else if f[j].Info.IsDir() {
+	} else if f[j].Entry.IsDir {
 		return false
 	}
-	return strings.Compare(f[i].Path, f[j].Path) < 0
+	return strings.Compare(f[i].Name, f[j].Name) < 0
 }
 
 func (f fileInfos) Swap(i, j int) {
 	f[i], f[j] = f[j], f[i]
 }
 
-type filesMap = map[string]fileInfo
-
 var (
-	quiet    = flag.Bool("q", false, "no screen output")
-	hashType = flag.String("hash", "md5", "hash type")
-
-	maxProcs int
-)
-
-func isFolder(name string) (bool, error) {
-	st, e := os.Stat(name)
-	if e != nil {
-		return false, e
-	}
-	return st.IsDir(), nil
-}
+	quiet = flag.Bool("q", false, "no screen output")
 
-func scanFolder(folder, root string) (fileInfos, error) {
-	fs, e := ioutil.ReadDir(folder)
-	if e != nil {
-		return nil, e
-	}
+	// maxProcs bounds the worker pool doPull fans out to. It defaults to
+	// NumCPU so callers that never go through main (tests, and any future
+	// embedder) still get a usable, buffered controlCh.
+	maxProcs = runtime.GOMAXPROCS(runtime.NumCPU())
 
-	lt := []fileInfo{}
-	for _, f := range fs {
-		name := f.Name()
-		fName := path.Join(root, name)
-		fPath := path.Join(folder, name)
-		info := fileInfo{
-			Name: fName,
-			Path: fPath,
-			Info: f,
-		}
-		lt = append(lt, info)
-		if f.IsDir() {
-			subLt, e := scanFolder(fPath, fName)
-			if e != nil {
-				return nil, e
-			}
-			lt = append(lt, subLt...)
-		}
-	}
-	return lt, nil
-}
+	stagingDir string
 
-func createMap(fs fileInfos) filesMap {
-	m := filesMap{}
-	for _, f := range fs {
-		m[f.Name] = f
-	}
-	return m
-}
+	// followSymlinks makes the walker treat a symlink as whatever it
+	// points to (the tool's original behavior) instead of preserving it
+	// as a symlink.
+	followSymlinks bool
 
-func hashMd5(name string) string {
-	f, e := os.Open(name)
-	if e != nil {
-		panic(e)
-	}
-	m := md5.New()
-	if _, e = io.Copy(m, f); e != nil {
-		panic(e)
-	}
-	f.Close()
-	return fmt.Sprintf("%x", m.Sum(nil))
-}
+	// preserveOwner chowns copied files to the source's UID/GID; it only
+	// has any effect when yqsync itself is running as root.
+	preserveOwner bool
+)
 
-func hashCrc32(name string) uint32 {
-	f, e := os.Open(name)
+// compareFolders walks src and dst together with a Walker and collects
+// the resulting events into a copy list (Added and Modified entries, from
+// src) and a delete list (Removed entries, from dst), in the same
+// dirs-first, lexicographic cp / deepest-first del order doPull and
+// doDelete already rely on. Collecting into these slices before doPull and
+// doDelete ever run means copying and deleting still only start once the
+// whole walk is done - Walker itself can stream events to a consumer as
+// it descends, but compareFolders isn't that consumer; what it does keep
+// from the walk is bounded peak memory while hashing (one frame per open
+// directory, not every file up front) and cheaper classification (no
+// src/dst map built over the whole tree before comparing).
+func compareFolders(src, dst backend.Backend, m *ignore.Matcher) (fileInfos, fileInfos, error) {
+	w, e := NewWalker(src, dst, m)
 	if e != nil {
-		panic(e)
-	}
-	c := crc32.NewIEEE()
-	if _, e = io.Copy(c, f); e != nil {
-		panic(e)
-	}
-	f.Close()
-	return c.Sum32()
-}
-
-func sameFile(f1, f2 string) bool {
-	ret := false
-	switch *hashType {
-	case "md5":
-		h1 := hashMd5(f1)
-		h2 := hashMd5(f2)
-		ret = h1 == h2
-	case "crc32":
-		h1 := hashCrc32(f1)
-		h2 := hashCrc32(f2)
-		ret = h1 == h2
-	}
-	return ret
-}
-
-func compareHash(srcF, dstF fileInfo, cpCh chan fileInfo, controlCh chan bool, wg *sync.WaitGroup) {
-	defer func() {
-		<-controlCh
-		wg.Done()
-	}()
-	wg.Add(1)
-	controlCh <- true
-	if !sameFile(srcF.Path, dstF.Path) {
-		cpCh <- srcF
+		return nil, nil, e
 	}
-}
 
-func compareFolders(src, dst filesMap) (fileInfos, fileInfos) {
-	controlCh := make(chan bool, maxProcs)
-	cpCh := make(chan fileInfo)
-	wg := sync.WaitGroup{}
-	go func(cpCh chan fileInfo, controlCh chan bool) {
-		for k, srcF := range src {
-			dstF, exisit := dst[k]
-			if srcF.Info.IsDir() {
-				if !exisit {
-					cpCh <- srcF
-				}
-			} else if !exisit {
-				cpCh <- srcF
-			} else {
-				go compareHash(srcF, dstF, cpCh, controlCh, &wg)
-			}
+	cp, del := fileInfos{}, fileInfos{}
+	for {
+		ev, e := w.Next()
+		if e == io.EOF {
+			break
 		}
-		wg.Wait()
-		close(controlCh)
-		close(cpCh)
-	}(cpCh, controlCh)
-
-	cp := fileInfos{}
-	for f := range cpCh {
-		cp = append(cp, f)
-	}
-	sort.Sort(cp)
-
-	del := fileInfos{}
-	for _, f := range dst {
-		if _, exisit := src[f.Name]; !exisit {
-			del = append(del, f)
+		if e != nil {
+			return nil, nil, e
+		}
+		switch ev.Kind {
+		case Added, Modified:
+			cp = append(cp, ev.Info)
+		case Removed:
+			del = append(del, ev.Info)
 		}
 	}
+	sort.Sort(cp)
 	sort.Sort(sort.Reverse(del))
 
-	return cp, del
-}
-
-func doCopy(dst string, cp fileInfos) error {
-	controlCh := make(chan bool, maxProcs)
-	wg := sync.WaitGroup{}
-	for _, f := range cp {
-		if f.Info.IsDir() {
-			if e := os.Mkdir(path.Join(dst, f.Name), f.Info.Mode()); e != nil {
-				return e
-			}
-		} else {
-			go func(f fileInfo, wg *sync.WaitGroup) {
-				defer func() {
-					<-controlCh
-					wg.Done()
-				}()
-				wg.Add(1)
-				controlCh <- true
-				fsrc, e := os.Open(f.Path)
-				if e != nil {
-					panic(e)
-				}
-				fdst, e := os.OpenFile(path.Join(dst, f.Name), os.O_CREATE|os.O_WRONLY, f.Info.Mode())
-				_, e = io.Copy(fdst, fsrc)
-				if e != nil {
-					panic(e)
-				}
-				fsrc.Close()
-				fdst.Close()
-			}(f, &wg)
-			if !*quiet {
-				log.Println("copy,", f.Name)
-			}
-		}
-	}
-	wg.Wait()
-	return nil
+	return cp, del, nil
 }
 
-func doDelete(del fileInfos) error {
+func doDelete(dst backend.Backend, del fileInfos, v versioner.Versioner) error {
 	for _, f := range del {
-		if e := os.Remove(f.Path); e != nil {
+		if e := v.Archive(dst, f.Name); e != nil {
 			return e
 		}
 		if !*quiet {
@@ -238,42 +136,38 @@ func doDelete(del fileInfos) error {
 	return nil
 }
 
-func syncFolder(src, dst string, isTest bool) (fileInfos, fileInfos, error) {
-	ok, e := isFolder(src)
+func syncFolder(src, dst backend.Backend, isTest bool, v versioner.Versioner, ignoreFile string) (fileInfos, fileInfos, error) {
+	srcRoot, e := src.Stat("")
 	if e != nil {
 		return nil, nil, e
 	}
-	if !ok {
+	if !srcRoot.IsDir {
 		return nil, nil, fmt.Errorf("source must be a folder")
 	}
 
-	ok, e = isFolder(dst)
+	dstRoot, e := dst.Stat("")
 	if e != nil {
 		return nil, nil, e
 	}
-	if !ok {
+	if !dstRoot.IsDir {
 		return nil, nil, fmt.Errorf("destination must be a folder")
 	}
 
-	srcLt, e := scanFolder(src, "")
+	m, e := ignore.Load(src, ignoreFile)
 	if e != nil {
 		return nil, nil, e
 	}
-	dstLt, e := scanFolder(dst, "")
+
+	cp, del, e := compareFolders(src, dst, m)
 	if e != nil {
 		return nil, nil, e
 	}
 
-	srcM := createMap(srcLt)
-	dstM := createMap(dstLt)
-
-	cp, del := compareFolders(srcM, dstM)
-
 	if !isTest {
-		if e = doDelete(del); e != nil {
+		if e = doDelete(dst, del, v); e != nil {
 			return nil, nil, e
 		}
-		if e = doCopy(dst, cp); e != nil {
+		if e = doPull(src, dst, cp); e != nil {
 			return nil, nil, e
 		}
 	}
@@ -284,7 +178,7 @@ func syncFolder(src, dst string, isTest bool) (fileInfos, fileInfos, error) {
 func save(name string, fs fileInfos) error {
 	l := []string{}
 	for _, f := range fs {
-		l = append(l, f.Path)
+		l = append(l, f.Name)
 	}
 	s := strings.Join(l, "\r\n") + "\r\n"
 	buf := bytes.NewBufferString(s)
@@ -303,22 +197,68 @@ func saveResult(cpName, delName string, cp, del fileInfos) error {
 	return nil
 }
 
-func main() {
-	maxProcs = runtime.GOMAXPROCS(runtime.NumCPU())
+// openBackend resolves a CLI-supplied -s/-d value to a Backend. URL-style
+// values pick the storage kind by scheme (file://, webdav://, s3://); a
+// bare path is treated as a local folder for backward compatibility.
+func openBackend(raw string) (backend.Backend, error) {
+	switch {
+	case strings.HasPrefix(raw, "file://"):
+		return backend.NewLocalBackend(strings.TrimPrefix(raw, "file://")), nil
+
+	case strings.HasPrefix(raw, "webdav://"):
+		u, e := url.Parse(raw)
+		if e != nil {
+			return nil, e
+		}
+		pass, _ := u.User.Password()
+		return backend.NewWebDAVBackend("https://"+u.Host, u.User.Username(), pass, u.Path), nil
 
+	case strings.HasPrefix(raw, "s3://"):
+		u, e := url.Parse(raw)
+		if e != nil {
+			return nil, e
+		}
+		cfg, e := config.LoadDefaultConfig(context.Background())
+		if e != nil {
+			return nil, e
+		}
+		return backend.NewS3Backend(s3.NewFromConfig(cfg), u.Host, u.Path), nil
+
+	default:
+		return backend.NewLocalBackend(raw), nil
+	}
+}
+
+func main() {
 	isTest := flag.Bool("test", false, "is test mode, would not sync")
 
 	cpuPprof := flag.String("pprof", "", "cpu profile")
 
-	src := flag.String("s", "./src", "source folder")
-	dst := flag.String("d", "./dst", "destination folder")
+	src := flag.String("s", "./src", "source folder (file://, webdav:// or s3:// URL, or a bare local path)")
+	dst := flag.String("d", "./dst", "destination folder (file://, webdav:// or s3:// URL, or a bare local path)")
 
 	listOut := flag.Bool("l", false, "output compared list to file")
 	cpFileName := flag.String("copyfile", "./copy.txt", "name of copy files list")
 	delFileName := flag.String("delfile", "./del.txt", "name of delete files list")
 
+	versionerName := flag.String("versioner", "none", "deletion versioner: none, trash, staggered")
+	staging := flag.String("staging", path.Join(stagingRoot, "tmp"), "relative staging directory under destination for in-progress writes")
+	ignoreFile := flag.String("ignore", ".yqignore", "ignore file to load from the source root, gitignore-style")
+
+	followLinks := flag.Bool("L", false, "follow symlinks instead of preserving them")
+	chown := flag.Bool("preserve-owner", false, "chown copied files to the source's UID/GID (root only)")
+
 	flag.Parse()
 
+	stagingDir = *staging
+	followSymlinks = *followLinks
+	preserveOwner = *chown
+
+	v, e := versioner.New(*versionerName)
+	if e != nil {
+		log.Fatal(e)
+	}
+
 	if len(*cpuPprof) > 0 {
 		f, e := os.Create(*cpuPprof)
 		if e != nil {
@@ -328,7 +268,16 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	cp, del, e := syncFolder(*src, *dst, *isTest)
+	srcBackend, e := openBackend(*src)
+	if e != nil {
+		log.Fatal(e)
+	}
+	dstBackend, e := openBackend(*dst)
+	if e != nil {
+		log.Fatal(e)
+	}
+
+	cp, del, e := syncFolder(srcBackend, dstBackend, *isTest, v, *ignoreFile)
 	if e != nil {
 		log.Fatal(e)
 		os.Exit(1)
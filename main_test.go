@@ -1,21 +1,41 @@
 package main
 
 import (
+	"io/ioutil"
+	"path"
 	"testing"
+
+	"yqsync/backend"
+	"yqsync/versioner"
 )
 
 func Test_SyncFolder(t *testing.T) {
-	if _, _, e := syncFolder("./src", "./dst", true); e != nil {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	if e := ioutil.WriteFile(path.Join(srcDir, "a.txt"), []byte("hi"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+	if _, _, e := syncFolder(src, dst, true, versioner.None{}, ".yqignore"); e != nil {
 		t.Fatalf(e.Error())
 	}
 }
 
 func Test_Output(t *testing.T) {
-	cp, del, e := syncFolder("./src", "./dst", true)
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	if e := ioutil.WriteFile(path.Join(srcDir, "a.txt"), []byte("hi"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+	cp, del, e := syncFolder(src, dst, true, versioner.None{}, ".yqignore")
 	if e != nil {
 		t.Fatalf(e.Error())
 	}
-	e = saveResult("copy.txt", "del.txt", cp, del)
+
+	e = saveResult(path.Join(t.TempDir(), "copy.txt"), path.Join(t.TempDir(), "del.txt"), cp, del)
 	if e != nil {
 		t.Fatalf(e.Error())
 	}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+
+	"yqsync/backend"
+)
+
+// doPull reconstructs each file in cp inside dst. Directories are created
+// outright; regular files go through pullFile, which reuses whatever
+// matching blocks it can when both sides are local. links tracks which
+// dst path first received each (dev,inode), so later hardlinks to an
+// already-copied file can be reconstructed instead of copied again.
+func doPull(src, dst backend.Backend, cp fileInfos) error {
+	controlCh := make(chan bool, maxProcs)
+	errCh := make(chan error, len(cp))
+	wg := sync.WaitGroup{}
+	links := newHardlinkTracker()
+
+	for _, f := range cp {
+		if f.Entry.IsDir {
+			if e := dst.Mkdir(f.Name, f.Entry.Mode); e != nil {
+				return e
+			}
+			continue
+		}
+		if !*quiet {
+			log.Println("pull,", f.Name)
+		}
+		wg.Add(1)
+		go func(f fileInfo) {
+			defer func() {
+				<-controlCh
+				wg.Done()
+			}()
+			controlCh <- true
+			if e := pullFile(src, dst, f, links); e != nil {
+				errCh <- e
+			}
+		}(f)
+	}
+	wg.Wait()
+	close(errCh)
+	for e := range errCh {
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// pullFile rebuilds a single destination file. When both sides are a
+// LocalBackend it takes the block-resuming fast path (which also handles
+// symlinks, hardlinks, xattrs and ownership); otherwise (any WebDAV/S3
+// side can't cheaply be range-read block by block, or carry any of that
+// local-only metadata) it falls back to a plain whole-file copy through
+// the Backend interface.
+func pullFile(src, dst backend.Backend, f fileInfo, links *hardlinkTracker) error {
+	srcLocal, srcOK := src.(*backend.LocalBackend)
+	dstLocal, dstOK := dst.(*backend.LocalBackend)
+	if srcOK && dstOK {
+		return pullFileLocal(srcLocal, dstLocal, f, links)
+	}
+	if f.LinkTarget != "" {
+		return fmt.Errorf("%s: preserving a symlink across non-local backends is not supported", f.Name)
+	}
+	return pullFileGeneric(src, dst, f)
+}
+
+func pullFileGeneric(src, dst backend.Backend, f fileInfo) error {
+	r, e := src.Open(f.Name)
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+
+	w, e := dst.Create(f.Name, f.Entry.Mode)
+	if e != nil {
+		return e
+	}
+	if _, e := io.Copy(w, r); e != nil {
+		w.Close()
+		return e
+	}
+	return w.Close()
+}
+
+// pullFileLocal rebuilds a single local-to-local destination path,
+// dispatching on what kind of entry f is: a preserved symlink is
+// recreated pointing at the same target, a hardlink to an already-copied
+// inode is relinked instead of copied again, and everything else goes
+// through the block-resuming regular-file path. Ownership and xattrs,
+// when the source had any, are applied afterwards.
+func pullFileLocal(srcLocal, dstLocal *backend.LocalBackend, f fileInfo, links *hardlinkTracker) error {
+	dstPath := dstLocal.Join(f.Name)
+
+	switch {
+	case f.LinkTarget != "":
+		if e := pullSymlinkLocal(dstPath, dstLocal.Join(stagingDir), f.LinkTarget); e != nil {
+			return e
+		}
+
+	case f.Nlink > 1:
+		key := hardlinkKey{dev: f.Dev, inode: f.Inode}
+		if firstPath, ok := links.claim(key, dstPath); ok {
+			if e := pullRegularFileLocal(srcLocal.Join(f.Name), dstPath, dstLocal.Join(stagingDir), f); e != nil {
+				links.abandon(key)
+				return e
+			}
+			links.done(key)
+		} else {
+			links.wait(key)
+			if e := linkLocal(firstPath, dstPath, dstLocal.Join(stagingDir)); e != nil {
+				return e
+			}
+		}
+
+	default:
+		if e := pullRegularFileLocal(srcLocal.Join(f.Name), dstPath, dstLocal.Join(stagingDir), f); e != nil {
+			return e
+		}
+	}
+
+	return applyOwnerAndXattrs(dstPath, f)
+}
+
+// applyOwnerAndXattrs replicates the source's xattrs, and (if running as
+// root with -preserve-owner) its UID/GID, onto the already-written dst
+// path. It uses the L-prefixed xattr/chown calls throughout so a
+// preserved symlink is affected itself, not whatever it points to.
+func applyOwnerAndXattrs(dstPath string, f fileInfo) error {
+	for name, v := range f.Xattrs {
+		if e := xattrLSet(dstPath, name, v); e != nil {
+			return e
+		}
+	}
+	if preserveOwner && os.Geteuid() == 0 {
+		if e := os.Lchown(dstPath, int(f.UID), int(f.GID)); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// pullSymlinkLocal recreates a symlink at dstPath via the same
+// stage-then-rename pattern as a regular file, so a crash never leaves a
+// half-written link.
+func pullSymlinkLocal(dstPath, stagingDir, target string) error {
+	if e := os.MkdirAll(stagingDir, 0755); e != nil {
+		return e
+	}
+	tmp, e := ioutil.TempFile(stagingDir, ".yqsync-tmp-")
+	if e != nil {
+		return e
+	}
+	tmpName := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpName)
+	defer os.Remove(tmpName)
+
+	if e := os.Symlink(target, tmpName); e != nil {
+		return e
+	}
+	return os.Rename(tmpName, dstPath)
+}
+
+// linkLocal reconstructs a hardlink at dstPath, pointing at the already-
+// copied firstPath, via the same stage-then-rename pattern.
+func linkLocal(firstPath, dstPath, stagingDir string) error {
+	if e := os.MkdirAll(stagingDir, 0755); e != nil {
+		return e
+	}
+	tmp, e := ioutil.TempFile(stagingDir, ".yqsync-tmp-")
+	if e != nil {
+		return e
+	}
+	tmpName := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpName)
+	defer os.Remove(tmpName)
+
+	if e := os.Link(firstPath, tmpName); e != nil {
+		return e
+	}
+	return os.Rename(tmpName, dstPath)
+}
+
+// pullRegularFileLocal rebuilds dstPath into a temp file under stagingDir,
+// block by block, reusing any block already present in the existing
+// destination file before falling back to reading it from srcPath,
+// fsyncs it, then renames it over dstPath.
+func pullRegularFileLocal(srcPath, dstPath, stagingDir string, f fileInfo) error {
+	matched := matchBlocks(dstPath, f.Blocks)
+
+	if e := os.MkdirAll(stagingDir, 0755); e != nil {
+		return e
+	}
+	tmp, e := ioutil.TempFile(stagingDir, ".yqsync-tmp-")
+	if e != nil {
+		return e
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	src, e := os.Open(srcPath)
+	if e != nil {
+		tmp.Close()
+		return e
+	}
+	defer src.Close()
+
+	var oldDst *os.File
+	if len(matched) > 0 {
+		if oldDst, e = os.Open(dstPath); e == nil {
+			defer oldDst.Close()
+		}
+	}
+
+	for _, b := range f.Blocks {
+		if off, ok := matched[b.Hash]; ok && oldDst != nil {
+			if _, e := oldDst.Seek(off, io.SeekStart); e == nil {
+				if _, e := io.CopyN(tmp, oldDst, int64(b.Size)); e == nil {
+					continue
+				}
+			}
+		}
+		if _, e := src.Seek(b.Offset, io.SeekStart); e != nil {
+			tmp.Close()
+			return e
+		}
+		if _, e := io.CopyN(tmp, src, int64(b.Size)); e != nil {
+			tmp.Close()
+			return e
+		}
+	}
+	if e := tmp.Sync(); e != nil {
+		tmp.Close()
+		return e
+	}
+	if e := tmp.Close(); e != nil {
+		return e
+	}
+	if e := os.Chmod(tmpName, f.Entry.Mode); e != nil {
+		return e
+	}
+	if e := os.Chtimes(tmpName, f.Entry.ModTime, f.Entry.ModTime); e != nil {
+		return e
+	}
+	return os.Rename(tmpName, dstPath)
+}
+
+// matchBlocks scans the existing file at dstPath with a sliding window and
+// returns, for every wanted block whose content is actually found there,
+// the offset it was found at. Destination regions are located by weak hash
+// first and only promoted to a match once the strong hash also agrees.
+func matchBlocks(dstPath string, blocks []BlockInfo) map[[sha256.Size]byte]int64 {
+	matched := map[[sha256.Size]byte]int64{}
+	if len(blocks) == 0 {
+		return matched
+	}
+
+	wanted := map[uint32][][sha256.Size]byte{}
+	for _, b := range blocks {
+		wanted[b.WeakHash] = append(wanted[b.WeakHash], b.Hash)
+	}
+
+	f, e := os.Open(dstPath)
+	if e != nil {
+		return matched
+	}
+	defer f.Close()
+
+	data, e := ioutil.ReadAll(f)
+	if e != nil || len(data) < BlockSize {
+		return matched
+	}
+
+	w := newWeakHash(data[:BlockSize])
+	for offset := 0; ; {
+		if candidates, ok := wanted[w.Sum()]; ok {
+			strong := sha256.Sum256(data[offset : offset+BlockSize])
+			for _, want := range candidates {
+				if want == strong {
+					if _, exists := matched[strong]; !exists {
+						matched[strong] = int64(offset)
+					}
+					break
+				}
+			}
+		}
+		if offset+BlockSize >= len(data) {
+			break
+		}
+		w.Roll(data[offset], data[offset+BlockSize])
+		offset++
+	}
+	return matched
+}
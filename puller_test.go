@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"yqsync/backend"
+)
+
+// Test_PullFileLocal_CrashMidCopy simulates a source that shrinks out from
+// under the puller mid-transfer (the same symptom a crash between "plan the
+// blocks" and "read them" would produce): the declared blocks promise more
+// bytes than the file actually has, so the copy must fail, and the existing
+// destination file must be left exactly as it was.
+func Test_PullFileLocal_CrashMidCopy(t *testing.T) {
+	dir := t.TempDir()
+	b := backend.NewLocalBackend(dir)
+
+	original := []byte("this destination content must survive a failed pull")
+	if e := ioutil.WriteFile(path.Join(dir, "dst.bin"), original, 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	srcContent := blockOfSeed(1)
+	if e := ioutil.WriteFile(path.Join(dir, "src.bin"), srcContent, 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	blocks, e := blocksForEntry(b, "src.bin")
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	// Truncate the source after planning its blocks, so the copy runs out
+	// of bytes partway through the block it promised.
+	if e := ioutil.WriteFile(path.Join(dir, "src.bin"), srcContent[:BlockSize/2], 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	entry, e := b.Stat("src.bin")
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	f := fileInfo{Name: "dst.bin", Entry: entry, Blocks: blocks}
+
+	if e := pullRegularFileLocal(b.Join("src.bin"), b.Join("dst.bin"), b.Join(".yqsync/tmp"), f); e == nil {
+		t.Fatalf("expected pullRegularFileLocal to fail on a truncated source")
+	}
+
+	got, e := ioutil.ReadFile(path.Join(dir, "dst.bin"))
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	if !bytes.Equal(got, original) {
+		t.Fatalf("destination was modified by a failed pull: got %q, want %q", got, original)
+	}
+}
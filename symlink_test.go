@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"yqsync/backend"
+	"yqsync/versioner"
+)
+
+// Test_SyncFolder_PreservesDanglingSymlink checks that a symlink whose
+// target doesn't exist is recreated as a symlink in dst, rather than
+// followed (which would fail outright) or dropped.
+func Test_SyncFolder_PreservesDanglingSymlink(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	if e := os.Symlink("does-not-exist", path.Join(srcDir, "dangling")); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+	if _, _, e := syncFolder(src, dst, false, versioner.None{}, ".yqignore"); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	target, e := os.Readlink(path.Join(dstDir, "dangling"))
+	if e != nil {
+		t.Fatalf("expected dangling to be recreated as a symlink: %v", e)
+	}
+	if target != "does-not-exist" {
+		t.Fatalf("symlink target = %q, want %q", target, "does-not-exist")
+	}
+}
+
+// Test_SyncFolder_ReconstructsHardlinks checks that two source files
+// sharing an inode end up sharing an inode in dst too, instead of each
+// becoming an independent copy.
+func Test_SyncFolder_ReconstructsHardlinks(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	if e := ioutil.WriteFile(path.Join(srcDir, "a.txt"), []byte("shared"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := os.Link(path.Join(srcDir, "a.txt"), path.Join(srcDir, "b.txt")); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+	if _, _, e := syncFolder(src, dst, false, versioner.None{}, ".yqignore"); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	fa, e := os.Stat(path.Join(dstDir, "a.txt"))
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	fb, e := os.Stat(path.Join(dstDir, "b.txt"))
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	if !os.SameFile(fa, fb) {
+		t.Fatalf("expected a.txt and b.txt to be reconstructed as the same inode in dst")
+	}
+}
@@ -0,0 +1,96 @@
+package versioner
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"yqsync/backend"
+)
+
+// staggeredIntervals mirrors Syncthing's default staggered schedule: keep
+// every version for the first hour, then thin older versions down to one
+// per widening bucket, and drop anything older than the last bucket.
+var staggeredIntervals = []time.Duration{
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+}
+
+// Staggered keeps deleted files under
+// <dst>/.yqsync/trash/<relPath>/<unixnano>, retaining at most one version
+// per bucket in an exponentially widening schedule (1h, 1d, 1w, 1mo) and
+// dropping anything older than the last bucket.
+type Staggered struct {
+	// Now defaults to time.Now when nil; overridable so tests don't depend
+	// on the wall clock.
+	Now func() time.Time
+}
+
+func (s Staggered) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// Archive implements Versioner.
+func (s Staggered) Archive(dst backend.Backend, relPath string) error {
+	now := s.now()
+	dir := path.Join(TrashDir, relPath)
+	to := path.Join(dir, strconv.FormatInt(now.UnixNano(), 10))
+	if e := moveWithinBackend(dst, relPath, to); e != nil {
+		return e
+	}
+	return s.prune(dst, dir, now)
+}
+
+// prune removes versions the staggered schedule no longer wants to keep: at
+// most one per bucket, nothing older than the last bucket.
+func (s Staggered) prune(dst backend.Backend, dir string, now time.Time) error {
+	entries, e := dst.ReadDir(dir)
+	if e != nil {
+		return nil // nothing to prune yet, or the dir isn't listable
+	}
+
+	type version struct {
+		name string
+		at   time.Time
+	}
+	versions := make([]version, 0, len(entries))
+	for _, entry := range entries {
+		nanos, e := strconv.ParseInt(strings.TrimSuffix(entry.Name, path.Ext(entry.Name)), 10, 64)
+		if e != nil {
+			continue
+		}
+		versions = append(versions, version{name: entry.Name, at: time.Unix(0, nanos)})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].at.After(versions[j].at) })
+
+	keptBucket := map[int]bool{}
+	for _, v := range versions {
+		age := now.Sub(v.at)
+		if age < staggeredIntervals[0] {
+			continue // always keep everything from the first interval
+		}
+
+		bucket := -1
+		for i := 1; i < len(staggeredIntervals); i++ {
+			if age < staggeredIntervals[i] {
+				bucket = i
+				break
+			}
+		}
+		if bucket == -1 || keptBucket[bucket] {
+			if e := dst.Remove(path.Join(dir, v.name)); e != nil {
+				return e
+			}
+			continue
+		}
+		keptBucket[bucket] = true
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package versioner
+
+import (
+	"path"
+
+	"yqsync/backend"
+)
+
+// TrashDir is where Trash keeps deleted files, relative to the destination
+// root.
+const TrashDir = ".yqsync/trash"
+
+// Trash moves a deleted file into <dst>/.yqsync/trash/<relPath> instead of
+// removing it, so it can be restored later. A file deleted more than once
+// simply overwrites its previous trashed copy.
+type Trash struct{}
+
+// Archive implements Versioner.
+func (Trash) Archive(dst backend.Backend, relPath string) error {
+	return moveWithinBackend(dst, relPath, path.Join(TrashDir, relPath))
+}
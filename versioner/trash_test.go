@@ -0,0 +1,63 @@
+package versioner
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"yqsync/backend"
+)
+
+func Test_Trash_RestoreAfterDelete(t *testing.T) {
+	dir := t.TempDir()
+	dst := backend.NewLocalBackend(dir)
+
+	content := []byte("keep me")
+	if e := ioutil.WriteFile(path.Join(dir, "a.txt"), content, 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	v := Trash{}
+	if e := v.Archive(dst, "a.txt"); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	if _, e := ioutil.ReadFile(path.Join(dir, "a.txt")); e == nil {
+		t.Fatalf("expected a.txt to be gone from the live tree after archiving")
+	}
+
+	got, e := ioutil.ReadFile(path.Join(dir, TrashDir, "a.txt"))
+	if e != nil {
+		t.Fatalf("expected a.txt to be restorable from trash: %v", e)
+	}
+	if string(got) != string(content) {
+		t.Fatalf("trashed content = %q, want %q", got, content)
+	}
+}
+
+func Test_Trash_ArchiveDirectory(t *testing.T) {
+	dir := t.TempDir()
+	dst := backend.NewLocalBackend(dir)
+
+	if e := os.Mkdir(path.Join(dir, "sub"), 0755); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	v := Trash{}
+	if e := v.Archive(dst, "sub"); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	if _, e := os.Stat(path.Join(dir, "sub")); e == nil {
+		t.Fatalf("expected sub to be gone from the live tree after archiving")
+	}
+
+	fi, e := os.Stat(path.Join(dir, TrashDir, "sub"))
+	if e != nil {
+		t.Fatalf("expected sub to be restorable from trash: %v", e)
+	}
+	if !fi.IsDir() {
+		t.Fatalf("expected trashed sub to still be a directory")
+	}
+}
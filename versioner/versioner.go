@@ -0,0 +1,111 @@
+// Package versioner decides what happens to a file syncFolder is about to
+// remove from the destination: keep a recoverable copy somewhere under
+// ".yqsync", or just delete it.
+package versioner
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"yqsync/backend"
+)
+
+// Versioner archives (or discards) a file at relPath in dst before it would
+// otherwise be deleted.
+type Versioner interface {
+	Archive(dst backend.Backend, relPath string) error
+}
+
+// None deletes files outright: the original, non-recoverable behavior.
+type None struct{}
+
+// Archive implements Versioner.
+func (None) Archive(dst backend.Backend, relPath string) error {
+	return dst.Remove(relPath)
+}
+
+// New returns the Versioner named by kind ("none", "trash" or "staggered").
+func New(kind string) (Versioner, error) {
+	switch kind {
+	case "", "none":
+		return None{}, nil
+	case "trash":
+		return Trash{}, nil
+	case "staggered":
+		return Staggered{}, nil
+	default:
+		return nil, &unknownVersionerError{kind}
+	}
+}
+
+type unknownVersionerError struct{ kind string }
+
+func (e *unknownVersionerError) Error() string {
+	return "unknown versioner: " + e.kind
+}
+
+// mkdirAll creates dir and all of its missing parents under dst, the way
+// os.MkdirAll does for a real filesystem, but through the Backend interface
+// so it also works against WebDAV/S3 destinations.
+func mkdirAll(dst backend.Backend, dir string) error {
+	if dir == "" || dir == "." {
+		return nil
+	}
+	parts := strings.Split(dir, "/")
+	cur := ""
+	for _, p := range parts {
+		cur = path.Join(cur, p)
+		if e := dst.Mkdir(cur, 0755); e != nil && !os.IsExist(e) {
+			if _, statErr := dst.Stat(cur); statErr != nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// moveWithinBackend moves the entry at from to to and removes from, since
+// Backend has no native rename. A directory is recreated at to rather
+// than opened/copied like a file - by the time a directory reaches here
+// its contents have already been archived or removed individually (del
+// is processed deepest-first, see compareFolders), so there is nothing
+// left to read out of it.
+func moveWithinBackend(dst backend.Backend, from, to string) error {
+	if e := mkdirAll(dst, path.Dir(to)); e != nil {
+		return e
+	}
+
+	entry, e := dst.Stat(from)
+	if e != nil {
+		return e
+	}
+
+	if entry.IsDir {
+		if e := dst.Mkdir(to, entry.Mode); e != nil {
+			return e
+		}
+		return dst.Remove(from)
+	}
+
+	r, e := dst.Open(from)
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+
+	w, e := dst.Create(to, entry.Mode)
+	if e != nil {
+		return e
+	}
+	if _, e := io.Copy(w, r); e != nil {
+		w.Close()
+		return e
+	}
+	if e := w.Close(); e != nil {
+		return e
+	}
+
+	return dst.Remove(from)
+}
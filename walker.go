@@ -0,0 +1,329 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"yqsync/backend"
+	"yqsync/ignore"
+)
+
+// EventKind classifies a single path as Walker descends src and dst
+// together.
+type EventKind int
+
+const (
+	Added EventKind = iota
+	Removed
+	Modified
+	Same
+)
+
+// Event is one classified path. Info carries the side a consumer should
+// act on: the src entry for Added/Modified (what to copy), the dst entry
+// for Removed (what to delete).
+type Event struct {
+	Kind EventKind
+	Info fileInfo
+}
+
+// frame is one directory level of the walk: both sides' entries, already
+// listed and sorted, with a merge cursor into each. A one-sided frame
+// (src-only or dst-only) has the other side's slice left nil, which the
+// merge in Walker.Next naturally drains as all-Added or all-Removed.
+type frame struct {
+	path  string
+	srcEs []backend.Entry
+	dstEs []backend.Entry
+	i, j  int
+}
+
+// Walker descends src and dst in lockstep, one directory level at a
+// time, comparing each level's entries lexicographically and classifying
+// each path as soon as both sides have reached it. It never holds more
+// than one frame per open directory, so peak memory is bounded by tree
+// depth rather than tree size, and a consumer can start acting on the
+// first events before the rest of either tree has even been listed.
+type Walker struct {
+	src, dst backend.Backend
+	m        *ignore.Matcher
+	stack    []*frame
+	pending  *Event
+}
+
+// NewWalker starts a walk of src and dst from their roots.
+func NewWalker(src, dst backend.Backend, m *ignore.Matcher) (*Walker, error) {
+	f, e := listFrame(src, dst, "")
+	if e != nil {
+		return nil, e
+	}
+	return &Walker{src: src, dst: dst, m: m, stack: []*frame{f}}, nil
+}
+
+func listFrame(src, dst backend.Backend, p string) (*frame, error) {
+	srcEs, e := listSorted(src, p)
+	if e != nil {
+		return nil, e
+	}
+	dstEs, e := listSorted(dst, p)
+	if e != nil {
+		return nil, e
+	}
+	return &frame{path: p, srcEs: srcEs, dstEs: dstEs}, nil
+}
+
+func listSorted(b backend.Backend, p string) ([]backend.Entry, error) {
+	es, e := b.ReadDir(p)
+	if e != nil {
+		return nil, e
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].Name < es[j].Name })
+	return es, nil
+}
+
+// Next returns the next classified path, or io.EOF once both trees are
+// exhausted.
+func (w *Walker) Next() (Event, error) {
+	if w.pending != nil {
+		ev := *w.pending
+		w.pending = nil
+		return ev, nil
+	}
+
+	for len(w.stack) > 0 {
+		top := w.stack[len(w.stack)-1]
+
+		if top.path == "" {
+			for top.i < len(top.srcEs) && top.srcEs[top.i].Name == stagingRoot {
+				top.i++
+			}
+			for top.j < len(top.dstEs) && top.dstEs[top.j].Name == stagingRoot {
+				top.j++
+			}
+		}
+
+		switch {
+		case top.i >= len(top.srcEs) && top.j >= len(top.dstEs):
+			w.stack = w.stack[:len(w.stack)-1]
+			continue
+
+		case top.j >= len(top.dstEs):
+			e := top.srcEs[top.i]
+			top.i++
+			ev, ok, err := w.added(top.path, e)
+			if err != nil {
+				return Event{}, err
+			}
+			if !ok {
+				continue
+			}
+			return ev, nil
+
+		case top.i >= len(top.srcEs):
+			e := top.dstEs[top.j]
+			top.j++
+			ev, ok, err := w.removed(top.path, e)
+			if err != nil {
+				return Event{}, err
+			}
+			if !ok {
+				continue
+			}
+			return ev, nil
+
+		default:
+			se, de := top.srcEs[top.i], top.dstEs[top.j]
+			switch {
+			case se.Name < de.Name:
+				top.i++
+				ev, ok, err := w.added(top.path, se)
+				if err != nil {
+					return Event{}, err
+				}
+				if !ok {
+					continue
+				}
+				return ev, nil
+
+			case se.Name > de.Name:
+				top.j++
+				ev, ok, err := w.removed(top.path, de)
+				if err != nil {
+					return Event{}, err
+				}
+				if !ok {
+					continue
+				}
+				return ev, nil
+
+			default:
+				top.i++
+				top.j++
+				ev, ok, err := w.same(top.path, se, de)
+				if err != nil {
+					return Event{}, err
+				}
+				if !ok {
+					continue
+				}
+				return ev, nil
+			}
+		}
+	}
+
+	return Event{}, io.EOF
+}
+
+// added classifies a src-only entry. Directories are pushed as a
+// one-sided frame so their contents are walked (and reported) too. A
+// preserved symlink (see followSymlinks) is reported with its target and
+// no blocks, since opening it would follow it (or fail, if dangling).
+func (w *Walker) added(dir string, e backend.Entry) (Event, bool, error) {
+	name := path.Join(dir, e.Name)
+	if w.m.Match(name, e.IsDir) {
+		return Event{}, false, nil
+	}
+	info := fileInfo{Name: name, Entry: e}
+	switch {
+	case e.IsDir:
+		sub, err := listSorted(w.src, name)
+		if err != nil {
+			return Event{}, false, err
+		}
+		w.stack = append(w.stack, &frame{path: name, srcEs: sub})
+
+	case !followSymlinks && e.Mode&os.ModeSymlink != 0:
+		info, err := localMeta(w.src, info)
+		if err != nil {
+			return Event{}, false, err
+		}
+		return Event{Kind: Added, Info: info}, true, nil
+
+	default:
+		blocks, err := blocksForEntry(w.src, name)
+		if err != nil {
+			return Event{}, false, err
+		}
+		info, err = localMeta(w.src, info)
+		if err != nil {
+			return Event{}, false, err
+		}
+		info.Blocks = blocks
+	}
+	return Event{Kind: Added, Info: info}, true, nil
+}
+
+// removed classifies a dst-only entry. Directories are pushed as a
+// one-sided frame so their contents are walked (and reported) too.
+func (w *Walker) removed(dir string, e backend.Entry) (Event, bool, error) {
+	name := path.Join(dir, e.Name)
+	if w.m.Match(name, e.IsDir) {
+		return Event{}, false, nil
+	}
+	info := fileInfo{Name: name, Entry: e}
+	if e.IsDir {
+		sub, err := listSorted(w.dst, name)
+		if err != nil {
+			return Event{}, false, err
+		}
+		w.stack = append(w.stack, &frame{path: name, dstEs: sub})
+	}
+	return Event{Kind: Removed, Info: info}, true, nil
+}
+
+// same classifies a name present on both sides. Equal-type directories
+// are pushed as a two-sided frame to recurse into. A type mismatch
+// (dir on one side, file on the other) is reported as a Removed of the
+// old entry followed (via pending) by an Added of the new one.
+func (w *Walker) same(dir string, se, de backend.Entry) (Event, bool, error) {
+	name := path.Join(dir, se.Name)
+	if w.m.Match(name, se.IsDir) {
+		return Event{}, false, nil
+	}
+
+	if se.IsDir != de.IsDir {
+		removed, _, err := w.removed(dir, de)
+		if err != nil {
+			return Event{}, false, err
+		}
+		added, ok, err := w.added(dir, se)
+		if err != nil {
+			return Event{}, false, err
+		}
+		if ok {
+			w.pending = &added
+		}
+		return removed, true, nil
+	}
+
+	if se.IsDir {
+		sub, err := listFrame(w.src, w.dst, name)
+		if err != nil {
+			return Event{}, false, err
+		}
+		w.stack = append(w.stack, sub)
+		return Event{}, false, nil
+	}
+
+	if !followSymlinks && (se.Mode&os.ModeSymlink != 0 || de.Mode&os.ModeSymlink != 0) {
+		return w.sameSymlink(name, se, de)
+	}
+
+	srcBlocks, dstBlocks, err := hashBothSides(w.src, w.dst, name)
+	if err != nil {
+		return Event{}, false, err
+	}
+
+	info := fileInfo{Name: name, Entry: se, Blocks: srcBlocks}
+	if sameBlocks(srcBlocks, dstBlocks) {
+		return Event{Kind: Same, Info: info}, true, nil
+	}
+	return Event{Kind: Modified, Info: info}, true, nil
+}
+
+// hashBothSides hashes the same-named file on src and dst concurrently,
+// since neither read depends on the other; doing them one after another
+// would make a same-vs-modified comparison take as long as both reads
+// combined instead of the slower of the two.
+func hashBothSides(src, dst backend.Backend, name string) ([]BlockInfo, []BlockInfo, error) {
+	var dstBlocks []BlockInfo
+	var dstErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dstBlocks, dstErr = blocksForEntry(dst, name)
+	}()
+
+	srcBlocks, srcErr := blocksForEntry(src, name)
+	<-done
+
+	if srcErr != nil {
+		return nil, nil, srcErr
+	}
+	if dstErr != nil {
+		return nil, nil, dstErr
+	}
+	return srcBlocks, dstBlocks, nil
+}
+
+// sameSymlink compares a path where either side is a symlink by target,
+// instead of hashing blocks (which would mean opening it - following it,
+// or failing outright if it's dangling).
+func (w *Walker) sameSymlink(name string, se, de backend.Entry) (Event, bool, error) {
+	srcInfo, err := localMeta(w.src, fileInfo{Name: name, Entry: se})
+	if err != nil {
+		return Event{}, false, err
+	}
+	if se.Mode&os.ModeSymlink != 0 && de.Mode&os.ModeSymlink != 0 {
+		dstInfo, err := localMeta(w.dst, fileInfo{Name: name, Entry: de})
+		if err != nil {
+			return Event{}, false, err
+		}
+		if srcInfo.LinkTarget == dstInfo.LinkTarget {
+			return Event{Kind: Same, Info: srcInfo}, true, nil
+		}
+	}
+	return Event{Kind: Modified, Info: srcInfo}, true, nil
+}
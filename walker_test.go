@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"yqsync/backend"
+	"yqsync/ignore"
+)
+
+func drainWalker(t *testing.T, w *Walker) []Event {
+	t.Helper()
+	var evs []Event
+	for {
+		ev, e := w.Next()
+		if e == io.EOF {
+			return evs
+		}
+		if e != nil {
+			t.Fatalf(e.Error())
+		}
+		evs = append(evs, ev)
+	}
+}
+
+func eventNames(evs []Event, kind EventKind) []string {
+	var names []string
+	for _, ev := range evs {
+		if ev.Kind == kind {
+			names = append(names, ev.Info.Name)
+		}
+	}
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_Walker_ClassifiesAddedRemovedModifiedSame(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+
+	write := func(dir, name, content string) {
+		if e := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); e != nil {
+			t.Fatalf(e.Error())
+		}
+	}
+	write(srcDir, "same.txt", "same")
+	write(dstDir, "same.txt", "same")
+	write(srcDir, "modified.txt", "new content")
+	write(dstDir, "modified.txt", "old content")
+	write(srcDir, "added.txt", "only in src")
+	write(dstDir, "removed.txt", "only in dst")
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+
+	w, e := NewWalker(src, dst, &ignore.Matcher{})
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	evs := drainWalker(t, w)
+
+	if added := eventNames(evs, Added); !contains(added, "added.txt") {
+		t.Fatalf("expected added.txt to be Added, got %v", added)
+	}
+	if removed := eventNames(evs, Removed); !contains(removed, "removed.txt") {
+		t.Fatalf("expected removed.txt to be Removed, got %v", removed)
+	}
+	if modified := eventNames(evs, Modified); !contains(modified, "modified.txt") {
+		t.Fatalf("expected modified.txt to be Modified, got %v", modified)
+	}
+	if same := eventNames(evs, Same); !contains(same, "same.txt") {
+		t.Fatalf("expected same.txt to be Same, got %v", same)
+	}
+}
+
+func Test_Walker_DescendsAddedAndRemovedSubdirs(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+
+	if e := os.Mkdir(path.Join(srcDir, "newdir"), 0755); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := ioutil.WriteFile(path.Join(srcDir, "newdir", "a.txt"), []byte("a"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := os.Mkdir(path.Join(dstDir, "olddir"), 0755); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := ioutil.WriteFile(path.Join(dstDir, "olddir", "b.txt"), []byte("b"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+
+	w, e := NewWalker(src, dst, &ignore.Matcher{})
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	evs := drainWalker(t, w)
+
+	added := eventNames(evs, Added)
+	if !contains(added, "newdir") || !contains(added, "newdir/a.txt") {
+		t.Fatalf("expected newdir and its contents to be Added, got %v", added)
+	}
+
+	removed := eventNames(evs, Removed)
+	if !contains(removed, "olddir") || !contains(removed, "olddir/b.txt") {
+		t.Fatalf("expected olddir and its contents to be Removed, got %v", removed)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+// weakHash is a rolling checksum modeled on Adler-32: it can be recomputed
+// in O(1) as a fixed-size window slides forward one byte at a time, which is
+// what lets the puller locate a matching block at an arbitrary (not just
+// block-aligned) offset in the destination file.
+type weakHash struct {
+	a, b uint32
+	n    uint32
+}
+
+// newWeakHash computes the initial checksum over data.
+func newWeakHash(data []byte) *weakHash {
+	w := &weakHash{n: uint32(len(data))}
+	for _, c := range data {
+		w.a += uint32(c)
+		w.b += w.a
+	}
+	return w
+}
+
+// Sum returns the current checksum value.
+func (w *weakHash) Sum() uint32 {
+	return w.a<<16 | (w.b & 0xffff)
+}
+
+// Roll slides the window forward by one byte: out leaves the window on the
+// left, in enters it on the right.
+func (w *weakHash) Roll(out, in byte) {
+	w.a = w.a - uint32(out) + uint32(in)
+	w.b = w.b - w.n*uint32(out) + w.a
+}
+
+// weakHashSum computes the weak hash of a single, non-rolling block.
+func weakHashSum(data []byte) uint32 {
+	return newWeakHash(data).Sum()
+}
@@ -0,0 +1,41 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/pkg/xattr"
+
+	"yqsync/backend"
+	"yqsync/versioner"
+)
+
+// Test_SyncFolder_PreservesXattr checks that a user.* xattr on a source
+// file is replicated onto the destination copy.
+func Test_SyncFolder_PreservesXattr(t *testing.T) {
+	srcDir, dstDir := t.TempDir(), t.TempDir()
+	p := path.Join(srcDir, "a.txt")
+	if e := ioutil.WriteFile(p, []byte("hi"), 0644); e != nil {
+		t.Fatalf(e.Error())
+	}
+	if e := xattr.Set(p, "user.test", []byte("value")); e != nil {
+		t.Skipf("xattrs not supported on this filesystem: %v", e)
+	}
+
+	src := backend.NewLocalBackend(srcDir)
+	dst := backend.NewLocalBackend(dstDir)
+	if _, _, e := syncFolder(src, dst, false, versioner.None{}, ".yqignore"); e != nil {
+		t.Fatalf(e.Error())
+	}
+
+	got, e := xattr.Get(path.Join(dstDir, "a.txt"), "user.test")
+	if e != nil {
+		t.Fatalf(e.Error())
+	}
+	if string(got) != "value" {
+		t.Fatalf("xattr value = %q, want %q", got, "value")
+	}
+}